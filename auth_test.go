@@ -0,0 +1,159 @@
+package s3website
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedURLAuthorizer(t *testing.T) {
+	authorizer := NewSignedURLAuthorizer([]byte("test-secret"))
+
+	const path = "/private/doc.pdf"
+
+	t.Run("valid signature authorizes", func(t *testing.T) {
+		query := authorizer.SignURL(path, time.Now().Add(time.Hour))
+		req := httptest.NewRequest(http.MethodGet, path+"?"+query, nil)
+
+		if status := authorizer.Authorize(req, path); status != 0 {
+			t.Errorf("Authorize() = %d, want 0", status)
+		}
+	})
+
+	t.Run("missing query params is unauthorized", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+
+		if status := authorizer.Authorize(req, path); status != http.StatusUnauthorized {
+			t.Errorf("Authorize() = %d, want %d", status, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("expired signature is unauthorized", func(t *testing.T) {
+		query := authorizer.SignURL(path, time.Now().Add(-time.Hour))
+		req := httptest.NewRequest(http.MethodGet, path+"?"+query, nil)
+
+		if status := authorizer.Authorize(req, path); status != http.StatusUnauthorized {
+			t.Errorf("Authorize() = %d, want %d", status, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("tampered signature is forbidden", func(t *testing.T) {
+		query := authorizer.SignURL(path, time.Now().Add(time.Hour))
+		req := httptest.NewRequest(http.MethodGet, path+"?"+query+"x", nil)
+
+		if status := authorizer.Authorize(req, path); status != http.StatusForbidden {
+			t.Errorf("Authorize() = %d, want %d", status, http.StatusForbidden)
+		}
+	})
+
+	t.Run("signature for a different path is forbidden", func(t *testing.T) {
+		query := authorizer.SignURL(path, time.Now().Add(time.Hour))
+		req := httptest.NewRequest(http.MethodGet, "/private/other.pdf?"+query, nil)
+
+		if status := authorizer.Authorize(req, "/private/other.pdf"); status != http.StatusForbidden {
+			t.Errorf("Authorize() = %d, want %d", status, http.StatusForbidden)
+		}
+	})
+}
+
+func TestJWTAuthorizerDefaultDeny(t *testing.T) {
+	authorizer := &JWTAuthorizer{
+		Rules: []JWTRule{
+			{PathPrefix: "/private/"},
+		},
+	}
+
+	t.Run("key matching no rule is denied by default", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/public/doc.pdf", nil)
+
+		if status := authorizer.Authorize(req, "/public/doc.pdf"); status != http.StatusForbidden {
+			t.Errorf("Authorize() = %d, want %d (default-deny)", status, http.StatusForbidden)
+		}
+	})
+
+	t.Run("key matching a rule without a bearer token is unauthorized", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/private/doc.pdf", nil)
+
+		if status := authorizer.Authorize(req, "/private/doc.pdf"); status != http.StatusUnauthorized {
+			t.Errorf("Authorize() = %d, want %d", status, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestServeHTTPWithAuthorizer(t *testing.T) {
+	authorizer := NewSignedURLAuthorizer([]byte("test-secret"))
+	client := newFakeS3Client(t, "test-bucket", map[string]fakeObject{
+		"private/doc.pdf": {body: []byte("secret contents"), contentType: "application/pdf"},
+	})
+
+	site := NewS3Website(client, "test-bucket", Config{}, WithAuthorizer(authorizer))
+
+	t.Run("missing signature is rejected with a plain 401", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/private/doc.pdf", nil)
+		rec := httptest.NewRecorder()
+
+		site.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+
+		if body := rec.Body.String(); strings.Contains(body, "secret contents") {
+			t.Errorf("body = %q, should not have served the bucket object", body)
+		}
+	})
+
+	t.Run("valid signature serves the object", func(t *testing.T) {
+		query := authorizer.SignURL("/private/doc.pdf", time.Now().Add(time.Hour))
+		req := httptest.NewRequest(http.MethodGet, "/private/doc.pdf?"+query, nil)
+		rec := httptest.NewRecorder()
+
+		site.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+
+		if got := rec.Body.String(); got != "secret contents" {
+			t.Errorf("body = %q, want %q", got, "secret contents")
+		}
+	})
+}
+
+func TestServeHTTPWithAuthorizerAndAuthErrorDocument(t *testing.T) {
+	authorizer := NewSignedURLAuthorizer([]byte("test-secret"))
+	client := newFakeS3Client(t, "test-bucket", map[string]fakeObject{
+		"private/doc.pdf": {body: []byte("secret contents"), contentType: "application/pdf"},
+		"401.html":        {body: []byte("please sign in"), contentType: "text/html"},
+	})
+
+	site := NewS3Website(client, "test-bucket", Config{AuthErrorDocument: "401.html"}, WithAuthorizer(authorizer))
+
+	req := httptest.NewRequest(http.MethodGet, "/private/doc.pdf", nil)
+	rec := httptest.NewRecorder()
+
+	site.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if got := rec.Body.String(); got != "please sign in" {
+		t.Errorf("body = %q, want the configured AuthErrorDocument's body", got)
+	}
+}
+
+func TestJWTAuthorizerMatchingRule(t *testing.T) {
+	tenantRule := JWTRule{PathPrefix: "/tenants/a/"}
+	authorizer := &JWTAuthorizer{Rules: []JWTRule{tenantRule, {PathPrefix: "/"}}}
+
+	if got := authorizer.matchingRule("/tenants/a/file.txt"); got == nil || got.PathPrefix != tenantRule.PathPrefix {
+		t.Errorf("matchingRule() = %+v, want the more specific rule", got)
+	}
+
+	if got := authorizer.matchingRule("/tenants/b/file.txt"); got == nil || got.PathPrefix != "/" {
+		t.Errorf("matchingRule() = %+v, want the catch-all rule", got)
+	}
+}
@@ -0,0 +1,90 @@
+package s3website
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+)
+
+// cacheEntry holds a precomputed, gzip-encoded response body for a single
+// version of an S3 object, identified by its key and etag, along with the
+// object's metadata headers (Content-Disposition, Content-Language,
+// Expires, and the x-amz-meta-* allow-list) so a cache hit can replay them
+// exactly as a live fetch would.
+type cacheEntry struct {
+	key         string
+	s3ETag      string
+	httpETag    string
+	contentType string
+	headers     http.Header
+	body        []byte
+}
+
+// cache is a bounded, in-memory LRU cache of cacheEntry values keyed by S3
+// object key. A lookup only counts as a hit when the entry's s3ETag matches
+// the object's current S3 etag, so a changed object is transparently treated
+// as a miss rather than requiring explicit invalidation.
+type cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+// newCache creates a cache that evicts least-recently-used entries once it
+// holds more than maxEntries entries or more than maxBytes bytes of response
+// bodies. A maxEntries or maxBytes of 0 means that bound is unlimited.
+func newCache(maxEntries int, maxBytes int64) *cache {
+	return &cache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (c *cache) get(key, s3ETag string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if entry.s3ETag != s3ETag {
+		return nil, false
+	}
+
+	c.ll.MoveToFront(elem)
+	return entry, true
+}
+
+func (c *cache) set(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[entry.key]; ok {
+		c.bytes -= int64(len(elem.Value.(*cacheEntry).body))
+		elem.Value = entry
+		c.ll.MoveToFront(elem)
+	} else {
+		c.items[entry.key] = c.ll.PushFront(entry)
+	}
+	c.bytes += int64(len(entry.body))
+
+	for (c.maxEntries > 0 && c.ll.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+
+		c.ll.Remove(oldest)
+		oldestEntry := oldest.Value.(*cacheEntry)
+		delete(c.items, oldestEntry.key)
+		c.bytes -= int64(len(oldestEntry.body))
+	}
+}
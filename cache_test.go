@@ -0,0 +1,93 @@
+package s3website
+
+import "testing"
+
+func TestCacheGetSet(t *testing.T) {
+	c := newCache(0, 0)
+
+	if _, ok := c.get("a", "etag-1"); ok {
+		t.Fatalf("get() on empty cache = hit, want miss")
+	}
+
+	c.set(&cacheEntry{key: "a", s3ETag: "etag-1", body: []byte("hello")})
+
+	entry, ok := c.get("a", "etag-1")
+	if !ok {
+		t.Fatalf("get() = miss, want hit")
+	}
+
+	if string(entry.body) != "hello" {
+		t.Errorf("get() body = %q, want %q", entry.body, "hello")
+	}
+}
+
+func TestCacheStaleETagIsAMiss(t *testing.T) {
+	c := newCache(0, 0)
+	c.set(&cacheEntry{key: "a", s3ETag: "etag-1", body: []byte("hello")})
+
+	if _, ok := c.get("a", "etag-2"); ok {
+		t.Fatalf("get() with a changed S3 etag = hit, want miss (stale entry should be treated as invalidated)")
+	}
+}
+
+func TestCacheSetOverwritesStaleEntry(t *testing.T) {
+	c := newCache(0, 0)
+	c.set(&cacheEntry{key: "a", s3ETag: "etag-1", body: []byte("old")})
+	c.set(&cacheEntry{key: "a", s3ETag: "etag-2", body: []byte("new")})
+
+	entry, ok := c.get("a", "etag-2")
+	if !ok {
+		t.Fatalf("get() = miss, want hit on the refreshed entry")
+	}
+
+	if string(entry.body) != "new" {
+		t.Errorf("get() body = %q, want %q", entry.body, "new")
+	}
+
+	if _, ok := c.get("a", "etag-1"); ok {
+		t.Errorf("get() on the superseded etag = hit, want miss")
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsedByMaxEntries(t *testing.T) {
+	c := newCache(2, 0)
+
+	c.set(&cacheEntry{key: "a", s3ETag: "1", body: []byte("a")})
+	c.set(&cacheEntry{key: "b", s3ETag: "1", body: []byte("b")})
+
+	// Touch "a" so it's the most-recently-used when "c" is added, leaving
+	// "b" as the least-recently-used entry to evict.
+	c.get("a", "1")
+	c.set(&cacheEntry{key: "c", s3ETag: "1", body: []byte("c")})
+
+	if _, ok := c.get("b", "1"); ok {
+		t.Errorf("get(\"b\") = hit, want miss (should have been evicted as least-recently-used)")
+	}
+
+	if _, ok := c.get("a", "1"); !ok {
+		t.Errorf("get(\"a\") = miss, want hit (recently touched, should survive eviction)")
+	}
+
+	if _, ok := c.get("c", "1"); !ok {
+		t.Errorf("get(\"c\") = miss, want hit (just inserted)")
+	}
+}
+
+func TestCacheEvictsByMaxBytes(t *testing.T) {
+	c := newCache(0, 10)
+
+	c.set(&cacheEntry{key: "a", s3ETag: "1", body: make([]byte, 6)})
+	c.set(&cacheEntry{key: "b", s3ETag: "1", body: make([]byte, 6)})
+
+	if _, ok := c.get("a", "1"); ok {
+		t.Errorf("get(\"a\") = hit, want miss (should have been evicted once the byte budget was exceeded)")
+	}
+
+	if _, ok := c.get("b", "1"); !ok {
+		t.Errorf("get(\"b\") = miss, want hit")
+	}
+
+	if c.bytes > 10 {
+		t.Errorf("cache.bytes = %d, want <= 10", c.bytes)
+	}
+}
@@ -0,0 +1,137 @@
+package s3website
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Config configures the S3 static-website hosting semantics that
+// S3Website.ServeHTTP implements, mirroring Amazon S3's own website
+// configuration.
+type Config struct {
+	// IndexDocument is the object served for a request path ending in "/",
+	// relative to that path. Defaults to "index.html" if empty.
+	IndexDocument string `json:"indexDocument,omitempty"`
+
+	// ErrorDocument is served, with ErrorStatusCode, when a requested key
+	// doesn't exist in the bucket and no RoutingRule applies. If empty,
+	// ServeHTTP falls back to http.NotFound.
+	ErrorDocument string `json:"errorDocument,omitempty"`
+
+	// ErrorStatusCode is the HTTP status written alongside ErrorDocument.
+	// Defaults to 404 if zero; set to 200 to use ErrorDocument as an SPA
+	// fallback.
+	ErrorStatusCode int `json:"errorStatusCode,omitempty"`
+
+	// RoutingRules are evaluated in order, mirroring S3 website hosting's
+	// RoutingRules. The first rule whose Condition matches the request is
+	// applied instead of serving from the bucket.
+	RoutingRules []RoutingRule `json:"routingRules,omitempty"`
+
+	// AuthErrorDocument is served when the configured Authorizer rejects a
+	// request, with the 401 or 403 status it returned. If empty, ServeHTTP
+	// falls back to a plain http.Error.
+	AuthErrorDocument string `json:"authErrorDocument,omitempty"`
+}
+
+// RoutingRule is a single entry in Config.RoutingRules: a condition that
+// must match the request, and the redirect to apply when it does.
+type RoutingRule struct {
+	Condition RoutingRuleCondition `json:"condition,omitempty"`
+	Redirect  RoutingRuleRedirect  `json:"redirect"`
+}
+
+// RoutingRuleCondition matches a request by key prefix and/or suffix. If
+// HTTPErrorCodeReturnedEquals is set, the rule only applies after the normal
+// lookup has failed with that status instead of being checked up front.
+type RoutingRuleCondition struct {
+	KeyPrefixEquals             string `json:"keyPrefixEquals,omitempty"`
+	KeySuffixEquals             string `json:"keySuffixEquals,omitempty"`
+	HTTPErrorCodeReturnedEquals int    `json:"httpErrorCodeReturnedEquals,omitempty"`
+}
+
+// RoutingRuleRedirect describes the redirect a matching RoutingRule issues.
+// ReplaceKeyWith takes precedence over ReplaceKeyPrefixWith if both are set.
+type RoutingRuleRedirect struct {
+	ReplaceKeyPrefixWith string `json:"replaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       string `json:"replaceKeyWith,omitempty"`
+	HTTPRedirectCode     int    `json:"httpRedirectCode,omitempty"`
+}
+
+// indexDocument returns the configured index document, or "index.html" if
+// none was configured.
+func (c Config) indexDocument() string {
+	if c.IndexDocument != "" {
+		return c.IndexDocument
+	}
+
+	return "index.html"
+}
+
+// matchRoutingRule returns the first rule whose condition matches key, and
+// whether any rule matched. errorCode is 0 for a rule checked before the
+// bucket lookup, or the HTTP status the lookup failed with.
+func matchRoutingRule(rules []RoutingRule, key string, errorCode int) (RoutingRule, bool) {
+	for _, rule := range rules {
+		condition := rule.Condition
+
+		if condition.HTTPErrorCodeReturnedEquals != errorCode {
+			continue
+		}
+
+		if condition.KeyPrefixEquals != "" && !strings.HasPrefix(key, condition.KeyPrefixEquals) {
+			continue
+		}
+
+		if condition.KeySuffixEquals != "" && !strings.HasSuffix(key, condition.KeySuffixEquals) {
+			continue
+		}
+
+		return rule, true
+	}
+
+	return RoutingRule{}, false
+}
+
+// applyRedirect issues the HTTP redirect described by rule.Redirect for a
+// request that matched rule at the given key.
+func applyRedirect(w http.ResponseWriter, req *http.Request, rule RoutingRule, key string) {
+	redirect := rule.Redirect
+
+	newKey := key
+	switch {
+	case redirect.ReplaceKeyWith != "":
+		newKey = redirect.ReplaceKeyWith
+	case redirect.ReplaceKeyPrefixWith != "":
+		newKey = redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, rule.Condition.KeyPrefixEquals)
+	}
+
+	code := redirect.HTTPRedirectCode
+	if code == 0 {
+		code = http.StatusFound
+	}
+
+	http.Redirect(w, req, newKey, code)
+}
+
+// statusWriter overrides any non-error status code written by the wrapped
+// ResponseWriter, letting callers like http.ServeContent or streamFile that
+// report their own 200/206/304 success path be made to report a different
+// status code instead (e.g. for an error document served with a custom
+// status). A request for the error/auth document can still carry a Range or
+// conditional header of its own (e.g. a <video> tag probing a broken link
+// with Range, or a client revalidating a previously-cached error page),
+// which would otherwise surface as a 206 or 304 instead of the configured
+// status, so every status below 400 is overridden, not just a literal 200.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if status < http.StatusBadRequest {
+		status = w.status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
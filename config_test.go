@@ -0,0 +1,159 @@
+package s3website
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMatchRoutingRule(t *testing.T) {
+	rules := []RoutingRule{
+		{
+			Condition: RoutingRuleCondition{KeyPrefixEquals: "videos/"},
+			Redirect:  RoutingRuleRedirect{ReplaceKeyPrefixWith: "assets/videos/"},
+		},
+		{
+			Condition: RoutingRuleCondition{KeySuffixEquals: ".php", HTTPErrorCodeReturnedEquals: http.StatusNotFound},
+			Redirect:  RoutingRuleRedirect{ReplaceKeyWith: "index.html"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		errorCode int
+		wantMatch bool
+		wantRule  int
+	}{
+		{name: "prefix match before any lookup", key: "videos/clip.mp4", errorCode: 0, wantMatch: true, wantRule: 0},
+		{name: "suffix rule ignored before lookup fails", key: "old.php", errorCode: 0, wantMatch: false},
+		{name: "suffix rule applies once lookup 404s", key: "old.php", errorCode: http.StatusNotFound, wantMatch: true, wantRule: 1},
+		{name: "no rule matches", key: "missing.txt", errorCode: http.StatusNotFound, wantMatch: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, ok := matchRoutingRule(rules, test.key, test.errorCode)
+			if ok != test.wantMatch {
+				t.Fatalf("matchRoutingRule(%q, %d) matched = %v, want %v", test.key, test.errorCode, ok, test.wantMatch)
+			}
+
+			if ok && rule.Redirect != rules[test.wantRule].Redirect {
+				t.Errorf("matchRoutingRule(%q, %d) = %+v, want rule %d", test.key, test.errorCode, rule, test.wantRule)
+			}
+		})
+	}
+}
+
+func TestApplyRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     RoutingRule
+		key      string
+		wantLoc  string
+		wantCode int
+	}{
+		{
+			name: "replace key prefix",
+			rule: RoutingRule{
+				Condition: RoutingRuleCondition{KeyPrefixEquals: "old/"},
+				Redirect:  RoutingRuleRedirect{ReplaceKeyPrefixWith: "new/"},
+			},
+			key:      "old/page.html",
+			wantLoc:  "/old/new/page.html",
+			wantCode: http.StatusFound,
+		},
+		{
+			name: "replace key wins over replace key prefix",
+			rule: RoutingRule{
+				Condition: RoutingRuleCondition{KeyPrefixEquals: "old/"},
+				Redirect:  RoutingRuleRedirect{ReplaceKeyPrefixWith: "new/", ReplaceKeyWith: "index.html", HTTPRedirectCode: http.StatusMovedPermanently},
+			},
+			key:      "old/page.html",
+			wantLoc:  "/old/index.html",
+			wantCode: http.StatusMovedPermanently,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+test.key, nil)
+			rec := httptest.NewRecorder()
+
+			applyRedirect(rec, req, test.rule, test.key)
+
+			if rec.Code != test.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, test.wantCode)
+			}
+
+			if got := rec.Header().Get("location"); got != test.wantLoc {
+				t.Errorf("location = %q, want %q", got, test.wantLoc)
+			}
+		})
+	}
+}
+
+func TestStatusWriterOverridesNonErrorStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   int
+	}{
+		{name: "200 is overridden", status: http.StatusOK, want: http.StatusTeapot},
+		{name: "206 is overridden", status: http.StatusPartialContent, want: http.StatusTeapot},
+		{name: "304 is overridden", status: http.StatusNotModified, want: http.StatusTeapot},
+		{name: "error statuses pass through", status: http.StatusInternalServerError, want: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			w := &statusWriter{rec, http.StatusTeapot}
+
+			w.WriteHeader(test.status)
+
+			if rec.Code != test.want {
+				t.Errorf("status = %d, want %d", rec.Code, test.want)
+			}
+		})
+	}
+}
+
+// TestNotFoundSPAFallbackIgnoresStaleConditionalHeaders reproduces a browser
+// that already has index.html cached (and so holds a conditional validator
+// for it) navigating to a client-side route that isn't a real object. With
+// ErrorStatusCode set to 200 for SPA fallback, the response must still come
+// back as a full 200 with a body, not collapse to a bodyless 304 just
+// because the stale validator happens to match index.html's own etag.
+func TestNotFoundSPAFallbackIgnoresStaleConditionalHeaders(t *testing.T) {
+	const indexETag = `"index-etag"`
+
+	client := newFakeS3Client(t, "test-bucket", map[string]fakeObject{
+		"index.html": {
+			body:         []byte("<html>spa shell</html>"),
+			contentType:  "text/html",
+			etag:         indexETag,
+			lastModified: time.Now(),
+		},
+	})
+
+	site := NewS3Website(client, "test-bucket", Config{
+		ErrorDocument:   "index.html",
+		ErrorStatusCode: http.StatusOK,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/some/client/route", nil)
+	req.Header.Set("if-none-match", indexETag)
+	rec := httptest.NewRecorder()
+
+	site.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if body := rec.Body.String(); body != "<html>spa shell</html>" {
+		t.Errorf("body = %q, want the index document's body, not empty", body)
+	}
+}
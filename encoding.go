@@ -0,0 +1,107 @@
+package s3website
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// encodingQualities holds the per-encoding q= weights parsed from an
+// Accept-Encoding header.
+type encodingQualities map[string]float64
+
+// parseAcceptEncoding parses an Accept-Encoding header value (RFC 7231
+// §5.3.4), including q= weights and the "*" wildcard.
+func parseAcceptEncoding(header string) encodingQualities {
+	qualities := make(encodingQualities)
+	if header == "" {
+		return qualities
+	}
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		encoding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if encoding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			name, value, ok := strings.Cut(param, "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+
+		qualities[encoding] = q
+	}
+
+	return qualities
+}
+
+// qualityOf returns the quality the client assigned to encoding, falling
+// back to the "*" wildcard and then to the RFC 7231 default of identity
+// being acceptable unless it was explicitly excluded (identity;q=0).
+func (q encodingQualities) qualityOf(encoding string) float64 {
+	if v, ok := q[encoding]; ok {
+		return v
+	}
+
+	if v, ok := q["*"]; ok {
+		return v
+	}
+
+	if encoding == "identity" {
+		return 1
+	}
+
+	return 0
+}
+
+// explicitlyExcluded reports whether the client's header names encoding (or
+// the "*" wildcard) with a quality of exactly 0, i.e. actively forbids it
+// rather than simply leaving it unmentioned.
+func (q encodingQualities) explicitlyExcluded(encoding string) bool {
+	if v, ok := q[encoding]; ok {
+		return v == 0
+	}
+
+	if v, ok := q["*"]; ok {
+		return v == 0
+	}
+
+	return false
+}
+
+// negotiateEncoding parses the request's Accept-Encoding header and returns
+// whichever of the given encodings (in preference order) the client accepts
+// with the highest quality. Ties between a preferred encoding and the
+// implicit identity default are broken in favor of the preferred encoding,
+// since a header like "gzip" (quality 1, same as identity's default) is
+// meant to opt into compression, not leave the client at identity. It
+// returns "identity" if none of the preferred encodings are acceptable.
+func negotiateEncoding(req *http.Request, preferred ...string) string {
+	qualities := parseAcceptEncoding(req.Header.Get("accept-encoding"))
+
+	bestEncoding := ""
+	bestQ := -1.0
+	for _, encoding := range preferred {
+		if qualities.explicitlyExcluded(encoding) {
+			continue
+		}
+
+		if q := qualities.qualityOf(encoding); q > bestQ {
+			bestEncoding, bestQ = encoding, q
+		}
+	}
+
+	if bestEncoding == "" || bestQ < qualities.qualityOf("identity") {
+		return "identity"
+	}
+
+	return bestEncoding
+}
@@ -0,0 +1,70 @@
+package s3website
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		preferred      []string
+		want           string
+	}{
+		{
+			name:           "plain gzip",
+			acceptEncoding: "gzip",
+			preferred:      []string{"gzip"},
+			want:           "gzip",
+		},
+		{
+			name:           "multi-value header prefers br over gzip",
+			acceptEncoding: "gzip, deflate, br",
+			preferred:      []string{"br", "gzip"},
+			want:           "br",
+		},
+		{
+			name:           "identity disallowed falls back to preferred",
+			acceptEncoding: "identity;q=0",
+			preferred:      []string{"gzip"},
+			want:           "gzip",
+		},
+		{
+			name:           "no header defaults to identity",
+			acceptEncoding: "",
+			preferred:      []string{"gzip"},
+			want:           "identity",
+		},
+		{
+			name:           "explicit identity only does not enable gzip",
+			acceptEncoding: "identity",
+			preferred:      []string{"gzip"},
+			want:           "identity",
+		},
+		{
+			name:           "gzip explicitly disallowed",
+			acceptEncoding: "gzip;q=0",
+			preferred:      []string{"gzip"},
+			want:           "identity",
+		},
+		{
+			name:           "gzip and identity both disallowed does not fall back to gzip",
+			acceptEncoding: "gzip;q=0, *;q=0",
+			preferred:      []string{"gzip"},
+			want:           "identity",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("accept-encoding", test.acceptEncoding)
+
+			if got := negotiateEncoding(req, test.preferred...); got != test.want {
+				t.Errorf("negotiateEncoding(%q, %v) = %q, want %q", test.acceptEncoding, test.preferred, got, test.want)
+			}
+		})
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"compress/gzip"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"io"
 	"mime"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -63,33 +66,66 @@ var (
 	}
 )
 
-func acceptEncodingGzip(req *http.Request) bool {
-	encodings := strings.Split(req.Header.Get("accept-encoding"), ",")
-	for _, encoding := range encodings {
-		if strings.TrimSpace(encoding) == "gzip" {
-			return true
-		}
-	}
+type S3Website struct {
+	client        *s3.S3
+	bucket        *string
+	config        Config
+	cache         *cache
+	precompressed bool
+	authorizer    Authorizer
+}
 
-	return false
+// Option configures optional behavior on an S3Website at construction time.
+type Option func(*S3Website)
+
+// WithCache enables an in-memory LRU cache of compressed, small responses so
+// that hot assets skip both the S3 fetch and the gzip encoding on repeat
+// requests. A maxEntries or maxBytes of 0 means that bound is unlimited.
+func WithCache(maxEntries int, maxBytes int64) Option {
+	return func(s *S3Website) {
+		s.cache = newCache(maxEntries, maxBytes)
+	}
 }
 
-type nopWriteCloser struct {
-	io.Writer
+// precompressedSuffixes maps a Content-Encoding to the sibling object key
+// suffix that holds the precompressed variant, in client-preference order.
+var precompressedSuffixes = []struct {
+	encoding string
+	suffix   string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
 }
 
-func (nopWriteCloser) Close() error { return nil }
+// WithPrecompressedVariants enables serving sibling key+".br" / key+".gz"
+// objects directly when the client's Accept-Encoding allows it, instead of
+// compressing the response on every request.
+func WithPrecompressedVariants() Option {
+	return func(s *S3Website) {
+		s.precompressed = true
+	}
+}
 
-type S3Website struct {
-	client *s3.S3
-	bucket *string
+// WithAuthorizer makes ServeHTTP consult authorizer before every request,
+// rejecting it with a 401 or 403 instead of serving from the bucket.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(s *S3Website) {
+		s.authorizer = authorizer
+	}
 }
 
-func NewS3Website(client *s3.S3, bucket string) *S3Website {
-	return &S3Website{
+func NewS3Website(client *s3.S3, bucket string, config Config, opts ...Option) *S3Website {
+	s := &S3Website{
 		client: client,
 		bucket: aws.String(bucket),
+		config: config,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 func (s *S3Website) headObject(key string) (*s3.HeadObjectOutput, error) {
@@ -110,15 +146,30 @@ func (s *S3Website) headObject(key string) (*s3.HeadObjectOutput, error) {
 	return headObjectOutput, nil
 }
 
-func (s *S3Website) getObject(key string) (*s3.GetObjectOutput, error) {
+// errRangeNotSatisfiable is returned by getObject in place of the S3 error
+// when a Range request falls outside the object's bounds, so callers can
+// distinguish it from a generic failure and reply with a 416 instead of a
+// 500 carrying a raw AWS SDK error string.
+var errRangeNotSatisfiable = errors.New("s3website: requested range not satisfiable")
+
+func (s *S3Website) getObject(key, byteRange string) (*s3.GetObjectOutput, error) {
 	getObjectInput := &s3.GetObjectInput{
 		Bucket: s.bucket,
 		Key:    aws.String(key),
 	}
 
+	if byteRange != "" {
+		getObjectInput.Range = aws.String(byteRange)
+	}
+
 	getObjectOutput, err := s.client.GetObject(getObjectInput)
-	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NoSuchKey" {
-		return nil, nil
+	if awsErr, ok := err.(awserr.Error); ok {
+		switch awsErr.Code() {
+		case "NoSuchKey":
+			return nil, nil
+		case "InvalidRange", "RequestedRangeNotSatisfiable":
+			return nil, errRangeNotSatisfiable
+		}
 	}
 
 	if err != nil {
@@ -128,8 +179,75 @@ func (s *S3Website) getObject(key string) (*s3.GetObjectOutput, error) {
 	return getObjectOutput, nil
 }
 
+// ifRangeSatisfied reports whether a Range request's If-Range precondition
+// still matches the object's current representation. An empty If-Range
+// header always satisfies the check, since no precondition was asked for.
+func (s *S3Website) ifRangeSatisfied(key, ifRange string) bool {
+	if ifRange == "" {
+		return true
+	}
+
+	headObjectOutput, err := s.headObject(key)
+	if err != nil || headObjectOutput == nil {
+		return false
+	}
+
+	if etag := aws.StringValue(headObjectOutput.ETag); etag != "" && etag == ifRange {
+		return true
+	}
+
+	if modTime, err := http.ParseTime(ifRange); err == nil {
+		return !aws.TimeValue(headObjectOutput.LastModified).After(modTime)
+	}
+
+	return false
+}
+
 func (s *S3Website) serveFile(w http.ResponseWriter, req *http.Request, key string) {
-	getObjectOutput, err := s.getObject(key)
+	byteRange := req.Header.Get("range")
+	if byteRange != "" && !s.ifRangeSatisfied(key, req.Header.Get("if-range")) {
+		byteRange = ""
+	}
+
+	if s.precompressed && byteRange == "" && s.servePrecompressed(w, req, key) {
+		return
+	}
+
+	wantsGzip := negotiateEncoding(req, "gzip") == "gzip"
+
+	// A cache hit serves a previously gzip-encoded response without
+	// fetching the object body from S3 at all. Range requests, and clients
+	// that can't accept gzip, always fall through to a live fetch below.
+	if s.cache != nil && byteRange == "" && wantsGzip {
+		headObjectOutput, err := s.headObject(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if headObjectOutput == nil {
+			http.NotFound(w, req)
+			return
+		}
+
+		if entry, ok := s.cache.get(key, aws.StringValue(headObjectOutput.ETag)); ok {
+			w.Header().Set("etag", entry.httpETag)
+			w.Header().Set("content-encoding", "gzip")
+			w.Header().Set("vary", "accept-encoding")
+			w.Header().Set("content-type", entry.contentType)
+			setCacheControl(w, headObjectOutput.CacheControl)
+			applyHeaders(w, entry.headers)
+			http.ServeContent(w, req, key, aws.TimeValue(headObjectOutput.LastModified), bytes.NewReader(entry.body))
+			return
+		}
+	}
+
+	getObjectOutput, err := s.getObject(key, byteRange)
+	if err == errRangeNotSatisfiable {
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -139,6 +257,7 @@ func (s *S3Website) serveFile(w http.ResponseWriter, req *http.Request, key stri
 		http.NotFound(w, req)
 		return
 	}
+	defer getObjectOutput.Body.Close()
 
 	// Get the file content-type from the S3 output.
 	var fileContentType string
@@ -152,9 +271,24 @@ func (s *S3Website) serveFile(w http.ResponseWriter, req *http.Request, key stri
 		fileContentType = mime.TypeByExtension(filepath.Ext(key))
 	}
 
-	// Set the response Content-Type header.
-	if fileContentType != "" {
-		w.Header().Set("content-type", fileContentType)
+	// As a last resort, sniff the first 512 bytes of the body itself.
+	var body io.Reader = getObjectOutput.Body
+	if fileContentType == "" {
+		sniffed, rest, err := sniffContentType(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		fileContentType = sniffed
+		body = rest
+	}
+
+	// Ranged requests and responses that aren't worth compressing stream
+	// straight through to the client instead of being buffered in memory.
+	if byteRange != "" || !compressableContentTypes[strings.Split(fileContentType, ";")[0]] || !wantsGzip {
+		s.streamFile(w, req, getObjectOutput, body, fileContentType)
+		return
 	}
 
 	var data *bytes.Buffer
@@ -164,24 +298,15 @@ func (s *S3Website) serveFile(w http.ResponseWriter, req *http.Request, key stri
 		data = bytes.NewBuffer([]byte{})
 	}
 
-	var dataWriteCloser io.WriteCloser
-
-	gzipEncoded := false
-	if compressableContentTypes[strings.Split(fileContentType, ";")[0]] && acceptEncodingGzip(req) {
-		gzipEncoded = true
-		dataWriteCloser = gzip.NewWriter(data)
-	} else {
-		dataWriteCloser = nopWriteCloser{data}
-	}
-
+	gzipWriter := gzip.NewWriter(data)
 	fileHash := sha256.New()
-	dataMultiWriter := io.MultiWriter(dataWriteCloser, fileHash)
-	if _, err := io.Copy(dataMultiWriter, getObjectOutput.Body); err != nil {
+	dataMultiWriter := io.MultiWriter(gzipWriter, fileHash)
+	if _, err := io.Copy(dataMultiWriter, body); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := dataWriteCloser.Close(); err != nil {
+	if err := gzipWriter.Close(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -190,30 +315,331 @@ func (s *S3Website) serveFile(w http.ResponseWriter, req *http.Request, key stri
 	fileHashSum := fileHash.Sum(nil)
 	etag := `"` + base64Encoding.EncodeToString(fileHashSum) + `"`
 	w.Header().Set("etag", etag)
+	w.Header().Set("content-encoding", "gzip")
+	w.Header().Set("vary", "accept-encoding")
+	w.Header().Set("content-type", fileContentType)
+	setCacheControl(w, getObjectOutput.CacheControl)
+	headers := objectHeaders(getObjectOutput)
+	applyHeaders(w, headers)
+
+	if s.cache != nil {
+		s.cache.set(&cacheEntry{
+			key:         key,
+			s3ETag:      aws.StringValue(getObjectOutput.ETag),
+			httpETag:    etag,
+			contentType: fileContentType,
+			headers:     headers,
+			body:        append([]byte(nil), data.Bytes()...),
+		})
+	}
 
-	// If the file is gzip-encoded, set a Content-Encoding header.
-	if gzipEncoded {
-		w.Header().Set("content-encoding", "gzip")
-		w.Header().Set("vary", "accept-encoding")
+	http.ServeContent(w, req, key, aws.TimeValue(getObjectOutput.LastModified), bytes.NewReader(data.Bytes()))
+}
+
+// servePrecompressed looks for a sibling key+".br" or key+".gz" object,
+// preferring whichever encoding the client's Accept-Encoding header ranks
+// highest, and streams it directly if one exists. It reports whether it
+// served the request.
+func (s *S3Website) servePrecompressed(w http.ResponseWriter, req *http.Request, key string) bool {
+	preferred := make([]string, len(precompressedSuffixes))
+	for i, p := range precompressedSuffixes {
+		preferred[i] = p.encoding
 	}
 
-	// Set a Content-Type header.
-	w.Header().Set("content-type", fileContentType)
+	encoding := negotiateEncoding(req, preferred...)
+	if encoding == "identity" {
+		return false
+	}
+
+	var suffix string
+	for _, p := range precompressedSuffixes {
+		if p.encoding == encoding {
+			suffix = p.suffix
+			break
+		}
+	}
+
+	variantKey := key + suffix
+	headObjectOutput, err := s.headObject(variantKey)
+	if err != nil || headObjectOutput == nil {
+		return false
+	}
+
+	getObjectOutput, err := s.getObject(variantKey, "")
+	if err != nil || getObjectOutput == nil {
+		return false
+	}
+	defer getObjectOutput.Body.Close()
+
+	etag := aws.StringValue(getObjectOutput.ETag)
+	lastModified := aws.TimeValue(getObjectOutput.LastModified)
+
+	if fileContentType := s.originalContentType(key); fileContentType != "" {
+		w.Header().Set("content-type", fileContentType)
+	}
+
+	w.Header().Set("content-encoding", encoding)
+	w.Header().Set("vary", "accept-encoding")
+	if etag != "" {
+		w.Header().Set("etag", etag)
+	}
+
+	if !lastModified.IsZero() {
+		w.Header().Set("last-modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	setCacheControl(w, getObjectOutput.CacheControl)
+	setObjectHeaders(w, getObjectOutput)
+
+	if notModified(req, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	if contentLength := aws.Int64Value(getObjectOutput.ContentLength); contentLength > 0 {
+		w.Header().Set("content-length", strconv.FormatInt(contentLength, 10))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodHead {
+		return true
+	}
 
-	// Set a Cache-Control header.
-	if cacheControl := aws.StringValue(getObjectOutput.CacheControl); cacheControl != "" {
-		w.Header().Set("cache-control", aws.StringValue(getObjectOutput.ContentType))
+	io.Copy(w, getObjectOutput.Body)
+	return true
+}
+
+// originalContentType returns the Content-Type S3 recorded for key, falling
+// back to a guess from its file extension. servePrecompressed uses this
+// instead of the precompressed variant's own Content-Type, since the variant
+// is typically recorded as e.g. "application/gzip" rather than the original
+// asset's type, and serving it shouldn't change what Content-Type the same
+// request gets without WithPrecompressedVariants.
+func (s *S3Website) originalContentType(key string) string {
+	if headObjectOutput, err := s.headObject(key); err == nil && headObjectOutput != nil {
+		if contentType := aws.StringValue(headObjectOutput.ContentType); contentType != "" {
+			return contentType
+		}
+	}
+
+	return mime.TypeByExtension(filepath.Ext(key))
+}
+
+// streamFile copies body (the, possibly re-wrapped, getObjectOutput.Body)
+// directly to the response without buffering it in memory, relaying S3's
+// own partial-content response when the caller asked for a byte range. It
+// honors conditional requests with a bare 304, and never writes a body for
+// a HEAD request.
+func (s *S3Website) streamFile(w http.ResponseWriter, req *http.Request, getObjectOutput *s3.GetObjectOutput, body io.Reader, fileContentType string) {
+	etag := aws.StringValue(getObjectOutput.ETag)
+	lastModified := aws.TimeValue(getObjectOutput.LastModified)
+
+	if fileContentType != "" {
+		w.Header().Set("content-type", fileContentType)
+	}
+
+	if etag != "" {
+		w.Header().Set("etag", etag)
+	}
+
+	if !lastModified.IsZero() {
+		w.Header().Set("last-modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	w.Header().Set("accept-ranges", "bytes")
+	setCacheControl(w, getObjectOutput.CacheControl)
+	setObjectHeaders(w, getObjectOutput)
+
+	if notModified(req, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if contentLength := aws.Int64Value(getObjectOutput.ContentLength); contentLength > 0 {
+		w.Header().Set("content-length", strconv.FormatInt(contentLength, 10))
+	}
+
+	status := http.StatusOK
+	if contentRange := aws.StringValue(getObjectOutput.ContentRange); contentRange != "" {
+		w.Header().Set("content-range", contentRange)
+		status = http.StatusPartialContent
+	}
+
+	w.WriteHeader(status)
+	if req.Method == http.MethodHead {
+		return
+	}
+
+	io.Copy(w, body)
+}
+
+// notModified reports whether req's conditional request headers
+// (If-None-Match, If-Modified-Since) are already satisfied by etag and
+// lastModified, meaning the caller should reply with a bare 304 instead of
+// re-sending the body.
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if inm := req.Header.Get("if-none-match"); inm != "" {
+		if etag == "" {
+			return false
+		}
+
+		for _, candidate := range strings.Split(inm, ",") {
+			if candidate := strings.TrimSpace(candidate); candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if ims := req.Header.Get("if-modified-since"); ims != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !lastModified.Truncate(time.Second).After(t)
+		}
+	}
+
+	return false
+}
+
+// setCacheControl sets the response's Cache-Control header from the S3
+// object's own CacheControl metadata, falling back to a short default.
+func setCacheControl(w http.ResponseWriter, cacheControl *string) {
+	if cc := aws.StringValue(cacheControl); cc != "" {
+		w.Header().Set("cache-control", cc)
 	} else {
 		w.Header().Set("cache-control", "max-age=60")
 	}
+}
 
-	http.ServeContent(w, req, key, aws.TimeValue(getObjectOutput.LastModified), bytes.NewReader(data.Bytes()))
+// allowedMetadataKeys is the curated set of S3 user-metadata keys (the part
+// after "x-amz-meta-") that get copied onto the response. Object metadata
+// isn't assumed safe to expose to clients, so only keys on this list are
+// ever propagated.
+var allowedMetadataKeys = []string{"cache-tag", "revision", "source"}
+
+// objectHeaders collects the curated x-amz-meta-* allow-list and the
+// standard Content-Disposition, Content-Language and Expires headers from an
+// S3 object, so they can be replayed onto a response either right away or,
+// for a cached entry, on a later cache hit.
+func objectHeaders(getObjectOutput *s3.GetObjectOutput) http.Header {
+	headers := make(http.Header)
+
+	for _, key := range allowedMetadataKeys {
+		if value := aws.StringValue(getObjectOutput.Metadata[key]); value != "" {
+			headers.Set("x-amz-meta-"+key, value)
+		}
+	}
+
+	if v := aws.StringValue(getObjectOutput.ContentDisposition); v != "" {
+		headers.Set("content-disposition", v)
+	}
+
+	if v := aws.StringValue(getObjectOutput.ContentLanguage); v != "" {
+		headers.Set("content-language", v)
+	}
+
+	if v := aws.StringValue(getObjectOutput.Expires); v != "" {
+		headers.Set("expires", v)
+	}
+
+	return headers
+}
+
+// setObjectHeaders copies the curated x-amz-meta-* allow-list and the
+// standard Content-Disposition, Content-Language and Expires headers from an
+// S3 object onto the response.
+func setObjectHeaders(w http.ResponseWriter, getObjectOutput *s3.GetObjectOutput) {
+	applyHeaders(w, objectHeaders(getObjectOutput))
+}
+
+// applyHeaders copies every value of every header in headers onto w.
+func applyHeaders(w http.ResponseWriter, headers http.Header) {
+	for key, values := range headers {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+}
+
+// sniffContentType reads up to 512 bytes from body to guess its content
+// type via http.DetectContentType, as is standard practice for object
+// stores that don't record a reliable MIME type. It returns the guessed
+// type along with a reader that reproduces the full, unconsumed body.
+func sniffContentType(body io.Reader) (string, io.Reader, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(body, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+
+	buf = buf[:n]
+	return http.DetectContentType(buf), io.MultiReader(bytes.NewReader(buf), body), nil
+}
+
+// substituteRequest clones req with its Range and conditional request
+// headers stripped, for use when serving a substitute document (an error or
+// auth error page) in place of the key the caller actually asked for. Those
+// headers were computed by the client against the originally requested
+// resource, not the substitute, so left in place they get evaluated against
+// the substitute's own etag/Last-Modified inside streamFile: a client
+// revalidating a cached index.html, say, can find its conditional headers
+// match the SPA fallback document too, collapsing the response to a bare
+// 304 with no body and losing the configured ErrorStatusCode entirely.
+func substituteRequest(req *http.Request) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Header.Del("Range")
+	clone.Header.Del("If-Range")
+	clone.Header.Del("If-None-Match")
+	clone.Header.Del("If-Modified-Since")
+	return clone
+}
+
+// notFound serves the configured error document in place of a bare 404, or
+// falls back to http.NotFound if none is configured.
+func (s *S3Website) notFound(w http.ResponseWriter, req *http.Request) {
+	if s.config.ErrorDocument == "" {
+		http.NotFound(w, req)
+		return
+	}
+
+	status := s.config.ErrorStatusCode
+	if status == 0 {
+		status = http.StatusNotFound
+	}
+
+	s.serveFile(&statusWriter{w, status}, substituteRequest(req), s.config.ErrorDocument)
+}
+
+// unauthorized serves the configured auth error document with status, or
+// falls back to a plain http.Error if none is configured.
+func (s *S3Website) unauthorized(w http.ResponseWriter, req *http.Request, status int) {
+	if s.config.AuthErrorDocument == "" {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	s.serveFile(&statusWriter{w, status}, substituteRequest(req), s.config.AuthErrorDocument)
 }
 
 func (s *S3Website) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	key := req.URL.Path
+
+	if s.authorizer != nil {
+		if status := s.authorizer.Authorize(req, key); status != 0 {
+			s.unauthorized(w, req, status)
+			return
+		}
+	}
+
+	indexDocument := s.config.indexDocument()
+
+	if rule, ok := matchRoutingRule(s.config.RoutingRules, key, 0); ok {
+		applyRedirect(w, req, rule, key)
+		return
+	}
+
 	if strings.HasSuffix(key, "/") {
-		s.serveFile(w, req, key+"index.html")
+		s.serveFile(w, req, key+indexDocument)
 		return
 	}
 
@@ -228,7 +654,7 @@ func (s *S3Website) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	headObjectOutput, err = s.headObject(key + "/index.html")
+	headObjectOutput, err = s.headObject(key + "/" + indexDocument)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -239,5 +665,10 @@ func (s *S3Website) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	http.NotFound(w, req)
+	if rule, ok := matchRoutingRule(s.config.RoutingRules, key, http.StatusNotFound); ok {
+		applyRedirect(w, req, rule, key)
+		return
+	}
+
+	s.notFound(w, req)
 }
@@ -0,0 +1,304 @@
+package s3website
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestServePrecompressedHead(t *testing.T) {
+	now := time.Now()
+	client := newFakeS3Client(t, "test-bucket", map[string]fakeObject{
+		"style.css.gz": {
+			body:         []byte("this is the gzip-compressed body"),
+			contentType:  "application/gzip",
+			etag:         `"gz-etag"`,
+			lastModified: now,
+		},
+		"style.css": {
+			contentType:  "text/css",
+			lastModified: now,
+		},
+	})
+
+	site := NewS3Website(client, "test-bucket", Config{}, WithPrecompressedVariants())
+
+	req := httptest.NewRequest(http.MethodHead, "/style.css", nil)
+	req.Header.Set("accept-encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	site.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if got := rec.Header().Get("content-encoding"); got != "gzip" {
+		t.Errorf("content-encoding = %q, want %q", got, "gzip")
+	}
+
+	if got := rec.Header().Get("content-type"); got != "text/css" {
+		t.Errorf("content-type = %q, want %q (the original asset's, not the variant's)", got, "text/css")
+	}
+
+	if body := rec.Body.String(); body != "" {
+		t.Errorf("HEAD response body = %q, want empty", body)
+	}
+}
+
+func TestServeFileRangeRequest(t *testing.T) {
+	now := time.Now()
+	client := newFakeS3Client(t, "test-bucket", map[string]fakeObject{
+		"video.mp4": {
+			body:         []byte("0123456789"),
+			contentType:  "video/mp4",
+			etag:         `"video-etag"`,
+			lastModified: now,
+		},
+	})
+
+	site := NewS3Website(client, "test-bucket", Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+	req.Header.Set("range", "bytes=2-4")
+	rec := httptest.NewRecorder()
+
+	site.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusPartialContent)
+	}
+
+	if got := rec.Header().Get("content-range"); got != "bytes 2-4/10" {
+		t.Errorf("content-range = %q, want %q", got, "bytes 2-4/10")
+	}
+
+	if got := rec.Body.String(); got != "234" {
+		t.Errorf("body = %q, want %q", got, "234")
+	}
+}
+
+func TestServeFileRangeNotSatisfiable(t *testing.T) {
+	client := newFakeS3Client(t, "test-bucket", map[string]fakeObject{
+		"video.mp4": {body: []byte("0123456789"), contentType: "video/mp4"},
+	})
+
+	site := NewS3Website(client, "test-bucket", Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+	req.Header.Set("range", "bytes=100-200")
+	rec := httptest.NewRecorder()
+
+	site.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestedRangeNotSatisfiable)
+	}
+}
+
+func TestServeFileIfRange(t *testing.T) {
+	now := time.Now()
+	client := newFakeS3Client(t, "test-bucket", map[string]fakeObject{
+		"video.mp4": {
+			body:         []byte("0123456789"),
+			contentType:  "video/mp4",
+			etag:         `"video-etag"`,
+			lastModified: now,
+		},
+	})
+
+	site := NewS3Website(client, "test-bucket", Config{})
+
+	t.Run("matching If-Range serves the partial response", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+		req.Header.Set("range", "bytes=0-2")
+		req.Header.Set("if-range", `"video-etag"`)
+		rec := httptest.NewRecorder()
+
+		site.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusPartialContent {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusPartialContent)
+		}
+
+		if got := rec.Body.String(); got != "012" {
+			t.Errorf("body = %q, want %q", got, "012")
+		}
+	})
+
+	t.Run("stale If-Range falls back to the full object", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+		req.Header.Set("range", "bytes=0-2")
+		req.Header.Set("if-range", `"stale-etag"`)
+		rec := httptest.NewRecorder()
+
+		site.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d (full object, If-Range precondition failed)", rec.Code, http.StatusOK)
+		}
+
+		if got := rec.Body.String(); got != "0123456789" {
+			t.Errorf("body = %q, want the full object", got)
+		}
+	})
+}
+
+func TestServeFileCacheHit(t *testing.T) {
+	now := time.Now()
+	client := newFakeS3Client(t, "test-bucket", map[string]fakeObject{
+		"style.css": {
+			contentType:  "text/css",
+			etag:         `"css-etag"`,
+			lastModified: now,
+		},
+	})
+
+	site := NewS3Website(client, "test-bucket", Config{}, WithCache(0, 0))
+	site.cache.set(&cacheEntry{
+		key:         "/style.css",
+		s3ETag:      `"css-etag"`,
+		httpETag:    `"cached-gzip-etag"`,
+		contentType: "text/css",
+		body:        []byte("cached gzip body"),
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+	req.Header.Set("accept-encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	site.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if got := rec.Header().Get("etag"); got != `"cached-gzip-etag"` {
+		t.Errorf("etag = %q, want the cached entry's httpETag", got)
+	}
+
+	if got := rec.Header().Get("content-encoding"); got != "gzip" {
+		t.Errorf("content-encoding = %q, want %q", got, "gzip")
+	}
+
+	if got := rec.Body.String(); got != "cached gzip body" {
+		t.Errorf("body = %q, want the cached body, not a live fetch", got)
+	}
+}
+
+func TestServePrecompressedNotModified(t *testing.T) {
+	now := time.Now()
+	client := newFakeS3Client(t, "test-bucket", map[string]fakeObject{
+		"style.css.gz": {
+			body:         []byte("this is the gzip-compressed body"),
+			contentType:  "application/gzip",
+			etag:         `"gz-etag"`,
+			lastModified: now,
+		},
+		"style.css": {
+			contentType:  "text/css",
+			lastModified: now,
+		},
+	})
+
+	site := NewS3Website(client, "test-bucket", Config{}, WithPrecompressedVariants())
+
+	req := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+	req.Header.Set("accept-encoding", "gzip")
+	req.Header.Set("if-none-match", `"gz-etag"`)
+	rec := httptest.NewRecorder()
+
+	site.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+
+	if body := rec.Body.String(); body != "" {
+		t.Errorf("304 response body = %q, want empty", body)
+	}
+}
+
+func TestSniffContentType(t *testing.T) {
+	body := strings.Repeat("a", 600)
+
+	sniffed, rest, err := sniffContentType(strings.NewReader("<html>" + body))
+	if err != nil {
+		t.Fatalf("sniffContentType() error = %v", err)
+	}
+
+	if !strings.Contains(sniffed, "text/html") {
+		t.Errorf("sniffContentType() = %q, want it to detect text/html", sniffed)
+	}
+
+	restBytes, err := io.ReadAll(rest)
+	if err != nil {
+		t.Fatalf("reading rest: %v", err)
+	}
+
+	if got := string(restBytes); got != "<html>"+body {
+		t.Errorf("rest reader did not reproduce the full body; got %d bytes, want %d", len(got), len("<html>"+body))
+	}
+}
+
+func TestObjectHeaders(t *testing.T) {
+	getObjectOutput := &s3.GetObjectOutput{
+		Metadata: map[string]*string{
+			"revision": aws.String("42"),
+			"secret":   aws.String("should-not-be-exposed"),
+		},
+		ContentDisposition: aws.String(`attachment; filename="report.pdf"`),
+		ContentLanguage:    aws.String("en-US"),
+		Expires:            aws.String("Thu, 01 Jan 2026 00:00:00 GMT"),
+	}
+
+	headers := objectHeaders(getObjectOutput)
+
+	if got := headers.Get("x-amz-meta-revision"); got != "42" {
+		t.Errorf("x-amz-meta-revision = %q, want %q", got, "42")
+	}
+
+	if got := headers.Get("x-amz-meta-secret"); got != "" {
+		t.Errorf("x-amz-meta-secret = %q, want empty (not on the allow-list)", got)
+	}
+
+	if got := headers.Get("content-disposition"); got != `attachment; filename="report.pdf"` {
+		t.Errorf("content-disposition = %q", got)
+	}
+
+	if got := headers.Get("content-language"); got != "en-US" {
+		t.Errorf("content-language = %q", got)
+	}
+
+	if got := headers.Get("expires"); got != "Thu, 01 Jan 2026 00:00:00 GMT" {
+		t.Errorf("expires = %q", got)
+	}
+}
+
+func TestSetCacheControl(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheControl *string
+		want         string
+	}{
+		{name: "uses the object's own Cache-Control", cacheControl: aws.String("public, max-age=3600"), want: "public, max-age=3600"},
+		{name: "falls back to a short default when unset", cacheControl: nil, want: "max-age=60"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			setCacheControl(rec, test.cacheControl)
+
+			if got := rec.Header().Get("cache-control"); got != test.want {
+				t.Errorf("cache-control = %q, want %q", got, test.want)
+			}
+		})
+	}
+}